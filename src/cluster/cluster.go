@@ -0,0 +1,105 @@
+// Package cluster implements a consistent hash ring over shards so a
+// GoMiniDistro deployment can scale horizontally by running more than one
+// Raft replica group ("shard"), each owning a slice of the key space,
+// instead of a single group owning every key.
+package cluster
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// virtualNodesPerShard controls how evenly keys spread across shards: each
+// shard gets this many points on the ring instead of just one.
+const virtualNodesPerShard = 128
+
+// Cluster tracks the current shard membership and answers "who owns this
+// key" queries against a consistent hash ring built from that membership.
+type Cluster struct {
+	mu      sync.RWMutex
+	members map[string]string // shardID -> address of the shard's registered entry point
+	ring    map[uint32]string // ring position -> shardID
+	sorted  []uint32          // ring positions, ascending, for binary search
+}
+
+// NewCluster returns an empty Cluster; call Join to add shards to it.
+func NewCluster() *Cluster {
+	return &Cluster{
+		members: make(map[string]string),
+		ring:    make(map[uint32]string),
+	}
+}
+
+func hashKey(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// Join adds or updates a shard's position on the ring.
+func (c *Cluster) Join(shardID, address string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.members[shardID] = address
+	for i := 0; i < virtualNodesPerShard; i++ {
+		c.ring[hashKey(fmt.Sprintf("%s#%d", shardID, i))] = shardID
+	}
+	c.rebuildSortedLocked()
+}
+
+// Leave removes a shard from the ring.
+func (c *Cluster) Leave(shardID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.members, shardID)
+	for i := 0; i < virtualNodesPerShard; i++ {
+		delete(c.ring, hashKey(fmt.Sprintf("%s#%d", shardID, i)))
+	}
+	c.rebuildSortedLocked()
+}
+
+func (c *Cluster) rebuildSortedLocked() {
+	c.sorted = make([]uint32, 0, len(c.ring))
+	for pos := range c.ring {
+		c.sorted = append(c.sorted, pos)
+	}
+	sort.Slice(c.sorted, func(i, j int) bool { return c.sorted[i] < c.sorted[j] })
+}
+
+// Owner returns the shard responsible for key and the address clients (or
+// other shards) should send requests for it to. ok is false if the ring is
+// empty.
+func (c *Cluster) Owner(key string) (shardID, address string, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.sorted) == 0 {
+		return "", "", false
+	}
+
+	h := hashKey(key)
+	idx := sort.Search(len(c.sorted), func(i int) bool { return c.sorted[i] >= h })
+	if idx == len(c.sorted) {
+		idx = 0 // wrap around the ring
+	}
+
+	shardID = c.ring[c.sorted[idx]]
+	address, ok = c.members[shardID]
+	return shardID, address, ok
+}
+
+// Members returns a snapshot of the current shardID -> address map.
+func (c *Cluster) Members() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := make(map[string]string, len(c.members))
+	for k, v := range c.members {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,301 @@
+// Package rpc is a small length-prefixed, binary-framed RPC protocol used
+// for inter-node traffic (the Raft RPCs, today). It replaces
+// one-HTTP-request-per-call with a single long-lived TCP connection per
+// peer, so the connection's handshake and header cost is paid once instead
+// of on every replication.
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MsgType identifies the payload carried by a frame. Today that's only the
+// two Raft RPCs; more message types get added here if/when something other
+// than raft.Node needs to talk over this protocol.
+type MsgType uint8
+
+const (
+	MsgRequestVote MsgType = iota
+	MsgAppendEntries
+)
+
+// frame wire format: [uint32 length][uint64 msgID][uint8 type][payload]
+// length counts everything after the length field itself.
+const frameHeaderSize = 8 + 1
+
+func writeFrame(w io.Writer, msgID uint64, typ MsgType, payload []byte) error {
+	header := make([]byte, 4+frameHeaderSize)
+	binary.BigEndian.PutUint32(header[0:4], uint32(frameHeaderSize+len(payload)))
+	binary.BigEndian.PutUint64(header[4:12], msgID)
+	header[12] = byte(typ)
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if len(payload) > 0 {
+		if _, err := w.Write(payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFrame(r io.Reader) (msgID uint64, typ MsgType, payload []byte, err error) {
+	var lenBuf [4]byte
+	if _, err = io.ReadFull(r, lenBuf[:]); err != nil {
+		return
+	}
+	size := binary.BigEndian.Uint32(lenBuf[:])
+	if size < frameHeaderSize {
+		err = fmt.Errorf("rpc: frame too small (%d bytes)", size)
+		return
+	}
+
+	body := make([]byte, size)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return
+	}
+
+	msgID = binary.BigEndian.Uint64(body[0:8])
+	typ = MsgType(body[8])
+	payload = body[frameHeaderSize:]
+	return
+}
+
+// Handler processes an incoming request frame and returns the response
+// payload to send back (errors are mapped to a frame of the same type
+// carrying an empty payload; the error itself is not sent or logged).
+type Handler func(typ MsgType, payload []byte) ([]byte, error)
+
+// Server accepts peer connections and dispatches each request frame it
+// reads to Handler, writing the result back on the same connection.
+type Server struct {
+	handler  Handler
+	listener net.Listener
+}
+
+// NewServer creates an RPC server that dispatches to handler.
+func NewServer(handler Handler) *Server {
+	return &Server{handler: handler}
+}
+
+// Listen starts accepting connections on addr. It returns once the listener
+// is bound; connections are served on background goroutines.
+func (s *Server) Listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return // listener closed
+			}
+			go s.serveConn(conn)
+		}
+	}()
+	return nil
+}
+
+// Close stops accepting new connections.
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	var writeMu sync.Mutex
+
+	for {
+		msgID, typ, payload, err := readFrame(reader)
+		if err != nil {
+			return
+		}
+
+		go func(msgID uint64, typ MsgType, payload []byte) {
+			resp, err := s.handler(typ, payload)
+			if err != nil {
+				resp = nil
+			}
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			writeFrame(conn, msgID, typ, resp)
+		}(msgID, typ, payload)
+	}
+}
+
+// Dialer opens a transport-level connection to addr, standing in for
+// net.DialTimeout. Client's default is plain TCP; a caller that needs to
+// substitute something else (the simulation harness injecting latency,
+// drops or partitions between peers) can supply its own via
+// NewClientWithDialer.
+type Dialer func(addr string) (net.Conn, error)
+
+// Client maintains one long-lived connection to a single peer and
+// multiplexes concurrent Call invocations over it, matching responses to
+// requests by msgID.
+type Client struct {
+	addr        string
+	dialTimeout time.Duration
+	dial        Dialer
+
+	mu      sync.Mutex
+	conn    net.Conn
+	nextID  uint64
+	pending map[uint64]chan result
+
+	writeMu sync.Mutex // serializes writeFrame calls, same as Server.serveConn
+}
+
+type result struct {
+	payload []byte
+	err     error
+}
+
+// NewClient creates a Client targeting addr. The connection is established
+// lazily on the first Call.
+func NewClient(addr string) *Client {
+	return NewClientWithDialer(addr, nil)
+}
+
+// NewClientWithDialer is NewClient with a custom Dialer in place of plain
+// net.DialTimeout. A nil dial falls back to the default.
+func NewClientWithDialer(addr string, dial Dialer) *Client {
+	return &Client{
+		addr:        addr,
+		dialTimeout: 2 * time.Second,
+		dial:        dial,
+		pending:     make(map[uint64]chan result),
+	}
+}
+
+func (c *Client) ensureConn() (net.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		return c.conn, nil
+	}
+
+	var conn net.Conn
+	var err error
+	if c.dial != nil {
+		conn, err = c.dial(c.addr)
+	} else {
+		conn, err = net.DialTimeout("tcp", c.addr, c.dialTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	go c.readLoop(conn)
+	return conn, nil
+}
+
+func (c *Client) readLoop(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		msgID, _, payload, err := readFrame(reader)
+		if err != nil {
+			c.abortPending(conn, err)
+			return
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[msgID]
+		delete(c.pending, msgID)
+		c.mu.Unlock()
+
+		if ok {
+			ch <- result{payload: payload}
+		}
+	}
+}
+
+// abortPending fails every in-flight call on conn and drops it so the next
+// Call reconnects.
+func (c *Client) abortPending(conn net.Conn, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == conn {
+		c.conn = nil
+	}
+	for id, ch := range c.pending {
+		ch <- result{err: err}
+		delete(c.pending, id)
+	}
+}
+
+// Call sends a request frame of the given type and blocks until the
+// matching response arrives, ctx is done, or timeout elapses - whichever
+// comes first, so a caller can cancel a call that's waiting on a hung peer.
+func (c *Client) Call(ctx context.Context, typ MsgType, payload []byte, timeout time.Duration) ([]byte, error) {
+	conn, err := c.ensureConn()
+	if err != nil {
+		return nil, err
+	}
+
+	msgID := atomic.AddUint64(&c.nextID, 1)
+	ch := make(chan result, 1)
+
+	c.mu.Lock()
+	c.pending[msgID] = ch
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	err = writeFrame(conn, msgID, typ, payload)
+	c.writeMu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, msgID)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-ch:
+		return res.payload, res.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, msgID)
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	case <-timer.C:
+		c.mu.Lock()
+		delete(c.pending, msgID)
+		c.mu.Unlock()
+		return nil, fmt.Errorf("rpc: call to %s timed out", c.addr)
+	}
+}
+
+// Close drops the underlying connection, if any.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
@@ -0,0 +1,202 @@
+// Package ws is a minimal RFC 6455 WebSocket server implementation - just
+// enough to upgrade an HTTP connection and exchange text frames. It exists
+// so the /watch change-feed endpoint doesn't need an external dependency;
+// go.mod requires none.
+package ws
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// handshakeGUID is fixed by RFC 6455 and appended to the client's
+// Sec-WebSocket-Key before hashing to produce Sec-WebSocket-Accept.
+const handshakeGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// Conn is an upgraded WebSocket connection.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Upgrade performs the WebSocket handshake on r and hijacks the underlying
+// TCP connection for framed read/write. The caller owns the returned Conn
+// and must Close it.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return nil, errors.New("ws: missing Upgrade: websocket header")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, errors.New("ws: missing Sec-WebSocket-Key header")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("ws: response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	accept := acceptKey(key)
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n"
+	if _, err := rw.WriteString(response); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Conn{conn: conn, reader: rw.Reader}, nil
+}
+
+func acceptKey(clientKey string) string {
+	h := sha1.New()
+	h.Write([]byte(clientKey + handshakeGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// WriteText sends payload as a single unmasked text frame (servers never
+// mask per RFC 6455).
+func (c *Conn) WriteText(payload []byte) error {
+	return c.writeFrame(opText, payload)
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	var header []byte
+	length := len(payload)
+
+	switch {
+	case length <= 125:
+		header = []byte{0x80 | opcode, byte(length)}
+	case length <= 0xFFFF:
+		header = make([]byte, 4)
+		header[0] = 0x80 | opcode
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = make([]byte, 10)
+		header[0] = 0x80 | opcode
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	if length > 0 {
+		if _, err := c.conn.Write(payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadMessage blocks until the client sends a text/binary frame, responding
+// to pings transparently and returning io.EOF once a close frame arrives.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case opClose:
+			c.writeFrame(opClose, nil)
+			return nil, io.EOF
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+		case opPong:
+			// nothing to do
+		default:
+			return payload, nil
+		}
+	}
+}
+
+// maxFramePayload caps the length a peer is allowed to claim for a single
+// frame's payload. Without a cap, the extended-length field is an
+// attacker-controlled uint64 read straight off the wire, and
+// make([]byte, length) on an absurd value panics with "makeslice: len out
+// of range" instead of returning an error.
+const maxFramePayload = 16 << 20 // 16 MiB
+
+func (c *Conn) readFrame() (opcode byte, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(c.reader, header); err != nil {
+		return 0, nil, err
+	}
+
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err = io.ReadFull(c.reader, ext); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err = io.ReadFull(c.reader, ext); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+
+	if length > maxFramePayload {
+		return 0, nil, fmt.Errorf("ws: frame payload too large (%d bytes)", length)
+	}
+
+	var maskKey [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.reader, maskKey[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return 0, nil, err
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= maskKey[i%4]
+		}
+	}
+
+	return opcode, payload, nil
+}
+
+// Close sends a close frame and closes the underlying connection.
+func (c *Conn) Close() error {
+	c.writeFrame(opClose, nil)
+	return c.conn.Close()
+}
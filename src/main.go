@@ -1,349 +1,21 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"strings"
-	"sync"
-)
-
-type Node struct {
-	isParent    bool
-	data        map[string]string
-	mu          sync.RWMutex
-	childNodes  []string
-	parentNode  string
-	selfAddress string
-}
-
-func NewNode(isParent bool, parentNode string, childNodes []string, selfAddress string) *Node {
-	return &Node{
-		data:        make(map[string]string),
-		isParent:    isParent,
-		parentNode:  parentNode,
-		childNodes:  childNodes,
-		selfAddress: selfAddress,
-	}
-}
-
-func (n *Node) Put(w http.ResponseWriter, r *http.Request) {
-
-	if !n.isParent {
-		if n.parentNode == "" {
-			http.Error(w, "Parent node not available", http.StatusInternalServerError)
-			return
-		}
-		http.Redirect(w, r, "http://"+n.parentNode+"/put", http.StatusTemporaryRedirect)
-		return
-	}
-
-	var body map[string]string
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
-		return
-	}
-
-	defer r.Body.Close()
-
-	key, keyOk := body["key"]
-	value, valueOk := body["value"]
-	if !keyOk || !valueOk {
-		http.Error(w, "Missing key or value in request", http.StatusBadRequest)
-		return
-	}
-
-	n.mu.Lock()
-	n.data[key] = value
-	n.mu.Unlock()
-
-	n.replicateToChildren(key, value)
-
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Stored: %s -> %s\n", key, value)
-}
-
-func (n *Node) Get(w http.ResponseWriter, r *http.Request) {
-	// Extract key from the query parameters
-	key := r.URL.Query().Get("key")
-	if key == "" {
-		http.Error(w, "Missing key in request", http.StatusBadRequest)
-		return
-	}
-
-	// Retrieve the value from the database
-	n.mu.RLock()
-	value, exists := n.data[key]
-	n.mu.RUnlock()
-
-	// Respond to the client
-	if !exists {
-		http.Error(w, "Key not found", http.StatusNotFound)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Value: %s\n", value)
-}
-
-func (n *Node) Delete(w http.ResponseWriter, r *http.Request) {
-	// Ensure the request uses the DELETE method
-	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	key := r.URL.Query().Get("key")
-	if key == "" {
-		http.Error(w, "Missing key in request", http.StatusBadRequest)
-		return
-	}
-
-	isReplication := r.Header.Get("X-Replication") == "true"
-
-	if !n.isParent {
-		if !isReplication {
-			// Redirect client-initiated delete requests to the parent node
-			if n.parentNode == "" {
-				http.Error(w, "Parent node not available", http.StatusInternalServerError)
-				return
-			}
-			http.Redirect(w, r, "http://"+n.parentNode+"/delete?key="+key, http.StatusTemporaryRedirect)
-			return
-		} else {
-			// Process replication delete request from parent node
-			n.mu.Lock()
-			delete(n.data, key)
-			n.mu.Unlock()
-			w.WriteHeader(http.StatusOK)
-			fmt.Fprintf(w, "Replicated deletion of key: %s\n", key)
-			return
-		}
-	}
-
-	// This is the parent node handling a client-initiated delete request
-	// Proceed to delete the key and replicate to children
-	n.mu.Lock()
-	delete(n.data, key)
-	n.mu.Unlock()
-
-	// Replicate the deletion to child nodes
-	n.replicateDeletionToChildren(key)
-
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Deleted key: %s\n", key)
-}
-
-func (n *Node) replicateDeletionToChildren(key string) {
-	for _, childAddr := range n.childNodes {
-		go func(addr string) {
-			// Create a DELETE request with the replication header
-			req, err := http.NewRequest(http.MethodDelete, "http://"+addr+"/delete?key="+key, nil)
-			if err != nil {
-				log.Printf("Failed to create DELETE request for %s: %v", addr, err)
-				return
-			}
-			req.Header.Set("X-Replication", "true") // Mark as a replication request
-
-			resp, err := http.DefaultClient.Do(req)
-			if err != nil {
-				log.Printf("Failed to replicate deletion to %s: %v", addr, err)
-				return
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				log.Printf("Replication to %s failed with status: %s", addr, resp.Status)
-			}
-		}(childAddr)
-	}
-}
-
-func (n *Node) replicateToChildren(key, value string) {
-	for _, childAddr := range n.childNodes {
-		go func(addr string) {
-			replicationData := map[string]string{"key": key, "value": value}
-			jsonData, _ := json.Marshal(replicationData)
-			resp, err := http.Post("http://"+addr+"/replicate", "application/json", bytes.NewBuffer(jsonData))
-			if err != nil {
-				log.Printf("Failed to replicate to %s: %v", addr, err)
-				resp.Body.Close()
-				return
-			}
-			resp.Body.Close()
-		}(childAddr)
-	}
-}
-
-func (n *Node) Replicate(w http.ResponseWriter, r *http.Request) {
-	if n.isParent {
-		http.Error(w, "Parent node cannot receive replication data", http.StatusBadRequest)
-		return
-	}
-
-	var body map[string]string
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, "Invalid replication payload", http.StatusBadRequest)
-		return
-	}
-
-	key, keyOk := body["key"]
-	value, valueOk := body["value"]
-	if !keyOk || !valueOk {
-		http.Error(w, "Missing key or value in replication data", http.StatusBadRequest)
-		return
-	}
-
-	n.mu.Lock()
-	n.data[key] = value
-	n.mu.Unlock()
-
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Replicated: %s -> %s\n", key, value)
-}
-
-func (n *Node) DisplayData(w http.ResponseWriter, r *http.Request) {
-
-	n.mu.RLock()
-	defer n.mu.RUnlock()
-
-	w.Header().Set("Content-Type", "application/json")
-
-	jsonData, err := json.Marshal(n.data)
-	if err != nil {
-		http.Error(w, "Error encoding data to JSON", http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	w.Write(jsonData)
-
-}
-
-// BETWEEN NODES TYPE OF STUFF
-func (n *Node) SetParentNode(w http.ResponseWriter, r *http.Request) {
-	if n.isParent {
-		http.Error(w, "Parent nodes cannot have a parent", http.StatusBadRequest)
-		return
-	}
+	"time"
 
-	// Parse the new parent node address from the request body
-	var body map[string]string
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
-
-	newParent, ok := body["parentNode"]
-	if !ok || newParent == "" {
-		http.Error(w, "Missing 'parentNode' in request", http.StatusBadRequest)
-		return
-	}
-
-	n.mu.Lock()
-	n.parentNode = newParent
-	n.mu.Unlock()
-
-	// Register with the parent node
-	err := n.registerWithParent()
-	if err != nil {
-		http.Error(w, "Failed to register with parent node: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Synchronize data from the parent node
-	err = n.synchronizeData()
-	if err != nil {
-		http.Error(w, "Failed to synchronize data: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Parent node updated to: %s\n", newParent)
-}
-
-func (n *Node) registerWithParent() error {
-	if n.selfAddress == "" {
-		return fmt.Errorf("self address not set")
-	}
-
-	registrationData := map[string]string{
-		"childNode": n.selfAddress,
-	}
-	jsonData, _ := json.Marshal(registrationData)
-
-	resp, err := http.Post("http://"+n.parentNode+"/addChild", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("registration failed: %s", string(bodyBytes))
-	}
-
-	return nil
-}
-
-func (n *Node) synchronizeData() error {
-	resp, err := http.Get("http://" + n.parentNode + "/display")
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("failed to synchronize data: %s", string(bodyBytes))
-	}
-
-	var data map[string]string
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return err
-	}
-
-	n.mu.Lock()
-	n.data = data
-	n.mu.Unlock()
-
-	return nil
-}
-
-func (n *Node) AddChildNode(w http.ResponseWriter, r *http.Request) {
-	if !n.isParent {
-		http.Error(w, "Only parent nodes can add child nodes", http.StatusBadRequest)
-		return
-	}
-
-	// Parse the new child node address from the request body
-	var body map[string]string
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
-
-	newChild, ok := body["childNode"]
-	if !ok || newChild == "" {
-		http.Error(w, "Missing 'childNode' in request", http.StatusBadRequest)
-		return
-	}
-
-	n.mu.Lock()
-	n.childNodes = append(n.childNodes, newChild)
-	n.mu.Unlock()
-
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Child node added: %s\n", newChild)
-}
+	"GoMiniDistro/src/cluster"
+	"GoMiniDistro/src/internal/rpc"
+	"GoMiniDistro/src/server"
+	"GoMiniDistro/src/simulations"
+	"GoMiniDistro/src/storage"
+)
 
 func GetSelfAddress(port string) (string, error) {
 	// Get the container IP address
@@ -370,18 +42,55 @@ func GetSelfAddress(port string) (string, error) {
 	return fmt.Sprintf("%s:%s", ipAddr, port), nil
 }
 
+// runSimulation serves the in-process simulation harness's JSON control API
+// on port instead of starting a real node - see Harness.RegisterHTTP for the
+// route list. It never returns.
+func runSimulation(port string) {
+	h := simulations.New()
+	mux := http.NewServeMux()
+	h.RegisterHTTP(mux)
+
+	fmt.Printf("Simulation control API running on port %s\n", port)
+	log.Fatal(http.ListenAndServe(":"+port, mux))
+}
+
 // INIT FUNCTION
 
 func main() {
 	// Define command-line flags
-	isParent := flag.Bool("parent", false, "Set to true if this is the parent node")
-	childNodes := flag.String("childNodes", "", "Comma-separated list of child node IPs if this is a parent node")
+	peerList := flag.String("peers", "", "Comma-separated list of every other node's address")
 	port := flag.String("port", "8080", "Port on which the node will run")
+	dataDir := flag.String("data-dir", "./data", "Directory for the WAL and snapshots")
+	snapshotInterval := flag.Duration("snapshot-interval", 30*time.Second, "How often to snapshot the data set and truncate the WAL")
+	walSyncFlag := flag.String("wal-sync", string(storage.SyncAlways), "WAL fsync policy: always|batch")
+	roleFlag := flag.String("role", string(server.RoleShard), "This node's shard role: shard|replica")
+	shardID := flag.String("shard-id", "default", "ID of the shard this node (and its --peers) belong to")
+	clusterSeeds := flag.String("cluster-seeds", "", "Comma-separated list of existing shard addresses to join the ring through")
+	rebalanceInterval := flag.Duration("rebalance-interval", time.Minute, "How often a shard leader hands off keys the ring has moved to another shard")
+	leaderAnnounceInterval := flag.Duration("leader-announce-interval", 2*time.Second, "How often a shard leader re-registers its address on the ring, so a new leader is picked up after an election")
+	sim := flag.Bool("sim", false, "Run the in-process simulation harness's control API instead of a real node, serving it on --port")
 
 	flag.Parse()
 
-	parentNodeEnv := os.Getenv("PARENT_NODE")
-	fmt.Println(parentNodeEnv)
+	if *sim {
+		runSimulation(*port)
+		return
+	}
+
+	role := server.Role(*roleFlag)
+	if role != server.RoleShard && role != server.RoleReplica {
+		log.Fatalf("invalid --role %q, want %q or %q", *roleFlag, server.RoleShard, server.RoleReplica)
+	}
+
+	walSync, err := storage.ParseSyncPolicy(*walSyncFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.MkdirAll(*dataDir, 0o755); err != nil {
+		log.Fatalf("Failed to create data dir: %v", err)
+	}
+
 	selfAddressEnv := os.Getenv("SELF_ADDRESS")
 
 	// Determine self address
@@ -397,43 +106,90 @@ func main() {
 		selfAddress = addr
 	}
 
-	// Parse child nodes if provided
-	var childNodeList []string
-	if *childNodes != "" {
-		childNodeList = strings.Split(*childNodes, ",")
+	// Parse peer addresses if provided
+	var peers []string
+	if *peerList != "" {
+		peers = strings.Split(*peerList, ",")
 	}
 
-	// Create a new node with the provided configuration
-	node := NewNode(*isParent, parentNodeEnv, childNodeList, selfAddress)
-
-	if !node.isParent {
-		if node.parentNode == "" {
-			log.Fatal("Parent node address is not set")
+	// Recover the data set: start from the newest snapshot, then replay any
+	// WAL records written after it.
+	snapshot, err := storage.LoadLatestSnapshot(*dataDir)
+	if err != nil {
+		log.Fatalf("Failed to load snapshot: %v", err)
+	}
+	maxSeq, err := storage.ReplayWAL(*dataDir, snapshot.Seq, func(rec storage.Record) {
+		switch rec.Op {
+		case "put":
+			snapshot.Data[rec.Key] = rec.Value
+		case "delete":
+			delete(snapshot.Data, rec.Key)
 		}
+		snapshot.Seq = rec.Seq
+	})
+	if err != nil {
+		log.Fatalf("Failed to replay WAL: %v", err)
+	}
+	if maxSeq > snapshot.Seq {
+		snapshot.Seq = maxSeq
+	}
 
-		// Register with the parent node
-		err := node.registerWithParent()
-		if err != nil {
-			log.Fatalf("Failed to register with parent node: %v", err)
-		}
+	wal, err := storage.OpenWAL(*dataDir, walSync)
+	if err != nil {
+		log.Fatalf("Failed to open WAL: %v", err)
+	}
+	wal.SeedSeq(snapshot.Seq)
 
-		// Synchronize data from the parent node
-		err = node.synchronizeData()
-		if err != nil {
-			log.Fatalf("Failed to synchronize data: %v", err)
+	// Create a new node with the provided configuration
+	ring := cluster.NewCluster()
+	node := server.NewNode(server.NodeConfig{
+		Peers:       peers,
+		SelfAddress: selfAddress,
+		WAL:         wal,
+		DataDir:     *dataDir,
+		InitialData: snapshot.Data,
+		WALSeq:      snapshot.Seq,
+		Role:        role,
+		ShardID:     *shardID,
+		Cluster:     ring,
+	})
+
+	if role == server.RoleShard {
+		ring.Join(*shardID, selfAddress)
+		for _, seed := range strings.Split(*clusterSeeds, ",") {
+			if seed == "" {
+				continue
+			}
+			if err := node.AnnounceToSeed(seed); err != nil {
+				log.Printf("cluster: failed to join via seed %s: %v", seed, err)
+			}
 		}
 	}
 
-	// Define HTTP routes and handlers
-	http.HandleFunc("/put", node.Put)
-	http.HandleFunc("/get", node.Get)
-	http.HandleFunc("/delete", node.Delete)
-	http.HandleFunc("/replicate", node.Replicate)
-	http.HandleFunc("/display", node.DisplayData)
-	http.HandleFunc("/setParent", node.SetParentNode)
-	http.HandleFunc("/addChild", node.AddChildNode)
+	raftListenAddr, err := node.RaftNode().ListenAddress()
+	if err != nil {
+		log.Fatalf("Failed to determine raft rpc address: %v", err)
+	}
+	rpcServer := rpc.NewServer(node.RaftNode().HandleRPC)
+	if err := rpcServer.Listen(raftListenAddr); err != nil {
+		log.Fatalf("Failed to start raft rpc server on %s: %v", raftListenAddr, err)
+	}
+
+	node.RaftNode().Start()
+	go node.SnapshotLoop(*snapshotInterval)
+	go node.FlushLoop()
+	if role == server.RoleShard {
+		go node.RebalanceLoop(*rebalanceInterval)
+		go node.LeaderAnnounceLoop(*leaderAnnounceInterval)
+	}
+
+	// Define HTTP routes and handlers - these remain the client-facing API,
+	// also used between shards for cluster joins and rebalance handoffs;
+	// Raft traffic between nodes goes over the binary rpc protocol above.
+	mux := http.NewServeMux()
+	server.RegisterRoutes(mux, node)
 
 	// Start the HTTP server on the specified port
-	fmt.Printf("Node running on port %s (Parent: %v, Parent Node: %s, Child Nodes: %v)\n", *port, *isParent, node.parentNode, childNodeList)
-	log.Fatal(http.ListenAndServe(":"+*port, nil))
+	fmt.Printf("Node running on port %s (self: %s, role: %s, shard: %s, peers: %v)\n", *port, selfAddress, role, *shardID, peers)
+	log.Fatal(http.ListenAndServe(":"+*port, mux))
 }
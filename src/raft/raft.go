@@ -0,0 +1,686 @@
+// Package raft implements a minimal Raft consensus module used to replicate
+// the key/value log across GoMiniDistro nodes. It only knows about terms,
+// logs and peer addresses; it has no idea what a "Put" or a "Delete" is -
+// the owning Node supplies an Apply callback that turns a committed Cmd into
+// a mutation of its own state.
+package raft
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"GoMiniDistro/src/internal/rpc"
+)
+
+// rpcPortOffset is added to a node's client-facing HTTP port to derive the
+// port its internal RPC server listens on. Addresses everywhere else in
+// this package (selfAddress, peers, votedFor, Leader()) stay HTTP
+// addresses so they can be handed straight to clients for redirects.
+const rpcPortOffset = 1000
+
+func rpcAddrFor(addr string) (string, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("raft: invalid address %q: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", fmt.Errorf("raft: invalid port in %q: %w", addr, err)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port+rpcPortOffset)), nil
+}
+
+// RaftState is the role a node currently believes it holds.
+type RaftState int
+
+const (
+	Follower RaftState = iota
+	Candidate
+	Leader
+)
+
+func (s RaftState) String() string {
+	switch s {
+	case Follower:
+		return "follower"
+	case Candidate:
+		return "candidate"
+	case Leader:
+		return "leader"
+	default:
+		return "unknown"
+	}
+}
+
+// Term counts elections; it only ever increases.
+type Term uint64
+
+// Cmd is the JSON-encoded payload carried by a LogEntry. Op is "put" or
+// "delete".
+type Cmd struct {
+	Op    string `json:"op"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// LogEntry is one slot in the replicated log.
+type LogEntry struct {
+	Term  Term   `json:"term"`
+	Index uint64 `json:"index"`
+	Cmd   Cmd    `json:"cmd"`
+}
+
+const (
+	electionTimeoutMin = 150 * time.Millisecond
+	electionTimeoutMax = 300 * time.Millisecond
+	heartbeatInterval  = 50 * time.Millisecond
+)
+
+// Dialer is an alias for rpc.Dialer so callers that only need to wire up a
+// Config don't have to import the rpc package themselves.
+type Dialer = rpc.Dialer
+
+// Config wires a Node to the rest of GoMiniDistro.
+type Config struct {
+	SelfAddress string
+	Peers       []string // addresses of every other node in the cluster
+	Apply       func(Cmd)
+
+	// Dialer, if set, replaces plain TCP dialing for every peer connection
+	// this Node opens, including ones added later via AddPeer. It exists
+	// for the simulation harness, which uses it to inject latency, drops
+	// and partitions between specific node pairs; production nodes leave
+	// it nil and get ordinary net.DialTimeout behavior.
+	Dialer Dialer
+}
+
+// Node is one Raft participant. All inter-node communication happens over
+// the binary framed protocol in internal/rpc: HandleRPC serves incoming
+// RequestVote/AppendEntries frames, and main.go registers it on an
+// rpc.Server listening on ListenAddress.
+type Node struct {
+	mu sync.Mutex
+
+	selfAddress string
+	peers       []string
+	apply       func(Cmd)
+
+	state       RaftState
+	currentTerm Term
+	votedFor    string
+	log         []LogEntry // 1-indexed; log[0] is a sentinel
+
+	commitIndex uint64
+	lastApplied uint64
+
+	nextIndex  map[string]uint64
+	matchIndex map[string]uint64
+
+	electionReset chan struct{}
+	waiters       map[uint64][]chan struct{}
+
+	dial    Dialer
+	clients map[string]*rpc.Client // peer (HTTP) address -> RPC client
+}
+
+// NewNode creates a Raft participant in the Follower state. Call Start to
+// begin the election timer and heartbeat loop.
+func NewNode(cfg Config) *Node {
+	n := &Node{
+		selfAddress:   cfg.SelfAddress,
+		peers:         cfg.Peers,
+		apply:         cfg.Apply,
+		state:         Follower,
+		log:           []LogEntry{{}}, // sentinel at index 0
+		nextIndex:     make(map[string]uint64),
+		matchIndex:    make(map[string]uint64),
+		electionReset: make(chan struct{}, 1),
+		waiters:       make(map[uint64][]chan struct{}),
+		dial:          cfg.Dialer,
+		clients:       make(map[string]*rpc.Client),
+	}
+	for _, peer := range cfg.Peers {
+		n.addClientLocked(peer)
+	}
+	return n
+}
+
+// addClientLocked creates the RPC client for peer if one doesn't already
+// exist. Callers must hold n.mu, except NewNode, which runs before n is
+// shared.
+func (n *Node) addClientLocked(peer string) {
+	if _, ok := n.clients[peer]; ok {
+		return
+	}
+	addr, err := rpcAddrFor(peer)
+	if err != nil {
+		log.Printf("raft: %v", err)
+		return
+	}
+	n.clients[peer] = rpc.NewClientWithDialer(addr, n.dial)
+}
+
+// AddPeer adds addr as an additional replication target, wiring up its RPC
+// client the same way Config.Peers does at construction time. It exists so
+// the simulation harness can build a topology up after nodes already exist
+// instead of only at NewNode; production nodes get their full peer set from
+// Config.Peers up front and never need it.
+func (n *Node) AddPeer(addr string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, p := range n.peers {
+		if p == addr {
+			return
+		}
+	}
+	n.peers = append(n.peers, addr)
+	n.addClientLocked(addr)
+	if n.state == Leader {
+		n.nextIndex[addr] = uint64(len(n.log))
+		n.matchIndex[addr] = 0
+	}
+}
+
+// Start launches the background election timer / heartbeat goroutines.
+func (n *Node) Start() {
+	go n.runElectionTimer()
+}
+
+// ListenAddress returns the address this node's RPC server should bind to,
+// derived from its own client-facing address.
+func (n *Node) ListenAddress() (string, error) {
+	return rpcAddrFor(n.selfAddress)
+}
+
+// State reports the node's current role and term.
+func (n *Node) State() (RaftState, Term) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.state, n.currentTerm
+}
+
+// IsLeader reports whether this node currently believes it is the leader.
+func (n *Node) IsLeader() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.state == Leader
+}
+
+func randomElectionTimeout() time.Duration {
+	span := electionTimeoutMax - electionTimeoutMin
+	return electionTimeoutMin + time.Duration(rand.Int63n(int64(span)))
+}
+
+func (n *Node) runElectionTimer() {
+	timer := time.NewTimer(randomElectionTimeout())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-n.electionReset:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(randomElectionTimeout())
+		case <-timer.C:
+			n.mu.Lock()
+			alreadyLeader := n.state == Leader
+			n.mu.Unlock()
+			if !alreadyLeader {
+				n.startElection()
+			}
+			timer.Reset(randomElectionTimeout())
+		}
+	}
+}
+
+func (n *Node) resetElectionTimer() {
+	select {
+	case n.electionReset <- struct{}{}:
+	default:
+	}
+}
+
+// startElection runs the candidate phase of leader election.
+func (n *Node) startElection() {
+	n.mu.Lock()
+	n.state = Candidate
+	n.currentTerm++
+	term := n.currentTerm
+	n.votedFor = n.selfAddress
+	lastIndex, lastTerm := n.lastLogInfoLocked()
+	peers := append([]string(nil), n.peers...)
+	n.mu.Unlock()
+
+	votes := 1 // vote for self
+	var votesMu sync.Mutex
+	done := make(chan struct{})
+
+	for _, peer := range peers {
+		go func(peer string) {
+			req := requestVoteRequest{
+				Term:         term,
+				CandidateID:  n.selfAddress,
+				LastLogIndex: lastIndex,
+				LastLogTerm:  lastTerm,
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), electionTimeoutMax)
+			defer cancel()
+
+			var resp requestVoteResponse
+			if err := n.call(ctx, peer, rpc.MsgRequestVote, req, &resp); err != nil {
+				return
+			}
+
+			n.mu.Lock()
+			if resp.Term > n.currentTerm {
+				n.becomeFollowerLocked(resp.Term)
+				n.mu.Unlock()
+				return
+			}
+			stillCandidate := n.state == Candidate && n.currentTerm == term
+			n.mu.Unlock()
+
+			if !stillCandidate || !resp.VoteGranted {
+				return
+			}
+
+			votesMu.Lock()
+			votes++
+			won := votes > (len(peers)+1)/2
+			votesMu.Unlock()
+
+			if won {
+				select {
+				case done <- struct{}{}:
+				default:
+				}
+			}
+		}(peer)
+	}
+
+	majority := (len(peers)+1)/2 + 1
+	select {
+	case <-done:
+	case <-time.After(electionTimeoutMax):
+	}
+
+	votesMu.Lock()
+	haveMajority := votes >= majority
+	votesMu.Unlock()
+
+	n.mu.Lock()
+	if haveMajority && n.state == Candidate && n.currentTerm == term {
+		n.becomeLeaderLocked()
+	}
+	n.mu.Unlock()
+}
+
+func (n *Node) becomeFollowerLocked(term Term) {
+	n.state = Follower
+	n.currentTerm = term
+	n.votedFor = ""
+}
+
+func (n *Node) becomeLeaderLocked() {
+	log.Printf("raft: %s elected leader for term %d", n.selfAddress, n.currentTerm)
+	n.state = Leader
+	lastIndex := uint64(len(n.log) - 1)
+	for _, peer := range n.peers {
+		n.nextIndex[peer] = lastIndex + 1
+		n.matchIndex[peer] = 0
+	}
+	go n.leaderLoop(n.currentTerm)
+}
+
+// leaderLoop sends periodic heartbeats (empty AppendEntries) until this node
+// steps down or a newer term is observed.
+func (n *Node) leaderLoop(term Term) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n.mu.Lock()
+		if n.state != Leader || n.currentTerm != term {
+			n.mu.Unlock()
+			return
+		}
+		peers := append([]string(nil), n.peers...)
+		n.mu.Unlock()
+
+		for _, peer := range peers {
+			go n.replicateTo(context.Background(), peer, term)
+		}
+	}
+}
+
+// Leader returns the address this node believes is the current leader, or
+// "" if unknown. Followers use this to redirect clients.
+func (n *Node) Leader() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.state == Leader {
+		return n.selfAddress
+	}
+	return n.votedFor // best-effort hint; refined once AppendEntries from the real leader arrives
+}
+
+func (n *Node) lastLogInfoLocked() (uint64, Term) {
+	last := n.log[len(n.log)-1]
+	return last.Index, last.Term
+}
+
+type requestVoteRequest struct {
+	Term         Term   `json:"term"`
+	CandidateID  string `json:"candidateId"`
+	LastLogIndex uint64 `json:"lastLogIndex"`
+	LastLogTerm  Term   `json:"lastLogTerm"`
+}
+
+type requestVoteResponse struct {
+	Term        Term `json:"term"`
+	VoteGranted bool `json:"voteGranted"`
+}
+
+// HandleRPC dispatches an incoming RPC frame to the matching Raft handler.
+// It is registered as the Handler for this node's rpc.Server.
+func (n *Node) HandleRPC(typ rpc.MsgType, payload []byte) ([]byte, error) {
+	switch typ {
+	case rpc.MsgRequestVote:
+		var req requestVoteRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return json.Marshal(n.handleRequestVote(req))
+	case rpc.MsgAppendEntries:
+		var req appendEntriesRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return json.Marshal(n.handleAppendEntries(req))
+	default:
+		return nil, fmt.Errorf("raft: unexpected message type %d", typ)
+	}
+}
+
+// handleRequestVote implements the RequestVote RPC.
+func (n *Node) handleRequestVote(req requestVoteRequest) requestVoteResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if req.Term > n.currentTerm {
+		n.becomeFollowerLocked(req.Term)
+	}
+
+	resp := requestVoteResponse{Term: n.currentTerm}
+
+	lastIndex, lastTerm := n.lastLogInfoLocked()
+	logUpToDate := req.LastLogTerm > lastTerm ||
+		(req.LastLogTerm == lastTerm && req.LastLogIndex >= lastIndex)
+
+	if req.Term == n.currentTerm && (n.votedFor == "" || n.votedFor == req.CandidateID) && logUpToDate {
+		n.votedFor = req.CandidateID
+		resp.VoteGranted = true
+		n.resetElectionTimer()
+	}
+
+	return resp
+}
+
+type appendEntriesRequest struct {
+	Term         Term       `json:"term"`
+	LeaderID     string     `json:"leaderId"`
+	PrevLogIndex uint64     `json:"prevLogIndex"`
+	PrevLogTerm  Term       `json:"prevLogTerm"`
+	Entries      []LogEntry `json:"entries"`
+	LeaderCommit uint64     `json:"leaderCommit"`
+}
+
+type appendEntriesResponse struct {
+	Term    Term `json:"term"`
+	Success bool `json:"success"`
+}
+
+// handleAppendEntries implements the AppendEntries RPC, used both for
+// heartbeats (Entries == nil) and for log replication.
+func (n *Node) handleAppendEntries(req appendEntriesRequest) appendEntriesResponse {
+	n.mu.Lock()
+
+	if req.Term < n.currentTerm {
+		resp := appendEntriesResponse{Term: n.currentTerm, Success: false}
+		n.mu.Unlock()
+		return resp
+	}
+
+	if req.Term > n.currentTerm || n.state == Candidate {
+		n.becomeFollowerLocked(req.Term)
+	}
+	n.state = Follower
+	n.votedFor = req.LeaderID
+	n.resetElectionTimer()
+
+	if req.PrevLogIndex >= uint64(len(n.log)) || n.log[req.PrevLogIndex].Term != req.PrevLogTerm {
+		resp := appendEntriesResponse{Term: n.currentTerm, Success: false}
+		n.mu.Unlock()
+		return resp
+	}
+
+	// Drop conflicting entries and append the new ones.
+	for i, entry := range req.Entries {
+		idx := req.PrevLogIndex + 1 + uint64(i)
+		if idx < uint64(len(n.log)) {
+			if n.log[idx].Term == entry.Term {
+				continue
+			}
+			n.log = n.log[:idx]
+		}
+		n.log = append(n.log, req.Entries[i:]...)
+		break
+	}
+
+	if req.LeaderCommit > n.commitIndex {
+		last := req.PrevLogIndex + uint64(len(req.Entries))
+		if req.LeaderCommit < last {
+			n.commitIndex = req.LeaderCommit
+		} else {
+			n.commitIndex = last
+		}
+	}
+	n.applyCommittedLocked()
+
+	resp := appendEntriesResponse{Term: n.currentTerm, Success: true}
+	n.mu.Unlock()
+	return resp
+}
+
+func (n *Node) applyCommittedLocked() {
+	for n.lastApplied < n.commitIndex {
+		n.lastApplied++
+		entry := n.log[n.lastApplied]
+		if n.apply != nil {
+			n.apply(entry.Cmd)
+		}
+		for _, ch := range n.waiters[entry.Index] {
+			close(ch)
+		}
+		delete(n.waiters, entry.Index)
+	}
+}
+
+// Propose appends cmd to the leader's log and blocks until it has been
+// replicated to a majority and applied, ctx is cancelled, or the timeout
+// elapses - whichever comes first. It returns false if this node is not
+// currently the leader. A cancelled ctx (e.g. the client hung up) lets the
+// caller stop waiting immediately instead of tying up the handler goroutine.
+func (n *Node) Propose(ctx context.Context, cmd Cmd, timeout time.Duration) (ok bool, isLeader bool) {
+	n.mu.Lock()
+	if n.state != Leader {
+		n.mu.Unlock()
+		return false, false
+	}
+
+	entry := LogEntry{
+		Term:  n.currentTerm,
+		Index: uint64(len(n.log)),
+		Cmd:   cmd,
+	}
+	n.log = append(n.log, entry)
+	n.matchIndex[n.selfAddress] = entry.Index
+
+	done := make(chan struct{})
+	n.waiters[entry.Index] = append(n.waiters[entry.Index], done)
+	peers := append([]string(nil), n.peers...)
+	term := n.currentTerm
+	n.mu.Unlock()
+
+	if len(peers) == 0 {
+		// Single-node cluster: commit immediately.
+		n.mu.Lock()
+		n.commitIndex = entry.Index
+		n.applyCommittedLocked()
+		n.mu.Unlock()
+		return true, true
+	}
+
+	for _, peer := range peers {
+		go n.replicateTo(ctx, peer, term)
+	}
+
+	select {
+	case <-done:
+		return true, true
+	case <-ctx.Done():
+		return false, true
+	case <-time.After(timeout):
+		return false, true
+	}
+}
+
+// replicateTo sends whatever entries the peer is missing, decrementing
+// nextIndex on a log-consistency rejection so the next call (the next
+// heartbeat tick) backs up one entry further, and advances
+// commitIndex once a majority has matched. Transient failures (a dropped
+// packet, a momentarily hung peer) are retried with backoff before giving
+// up for this round; the next heartbeat tick will simply try again.
+func (n *Node) replicateTo(ctx context.Context, peer string, term Term) {
+	n.mu.Lock()
+	if n.state != Leader || n.currentTerm != term {
+		n.mu.Unlock()
+		return
+	}
+	next := n.nextIndex[peer]
+	if next == 0 {
+		next = 1
+	}
+	prevIndex := next - 1
+	prevTerm := n.log[prevIndex].Term
+	entries := append([]LogEntry(nil), n.log[next:]...)
+	req := appendEntriesRequest{
+		Term:         term,
+		LeaderID:     n.selfAddress,
+		PrevLogIndex: prevIndex,
+		PrevLogTerm:  prevTerm,
+		Entries:      entries,
+		LeaderCommit: n.commitIndex,
+	}
+	n.mu.Unlock()
+
+	var resp appendEntriesResponse
+	if err := n.callWithRetry(ctx, peer, rpc.MsgAppendEntries, req, &resp); err != nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if resp.Term > n.currentTerm {
+		n.becomeFollowerLocked(resp.Term)
+		return
+	}
+	if n.state != Leader || n.currentTerm != term {
+		return
+	}
+
+	if resp.Success {
+		n.matchIndex[peer] = prevIndex + uint64(len(entries))
+		n.nextIndex[peer] = n.matchIndex[peer] + 1
+		n.advanceCommitIndexLocked()
+	} else if n.nextIndex[peer] > 1 {
+		n.nextIndex[peer]--
+	}
+}
+
+// advanceCommitIndexLocked moves commitIndex forward to the highest index
+// replicated on a majority of nodes (including self) for the current term.
+func (n *Node) advanceCommitIndexLocked() {
+	for idx := uint64(len(n.log) - 1); idx > n.commitIndex; idx-- {
+		if n.log[idx].Term != n.currentTerm {
+			continue
+		}
+		matches := 1 // self
+		for _, peer := range n.peers {
+			if n.matchIndex[peer] >= idx {
+				matches++
+			}
+		}
+		if matches > (len(n.peers)+1)/2 {
+			n.commitIndex = idx
+			n.applyCommittedLocked()
+			return
+		}
+	}
+}
+
+// rpcCallTimeout bounds how long a single RequestVote/AppendEntries RPC
+// waits for a reply over the peer's persistent connection.
+const rpcCallTimeout = 2 * time.Second
+
+// replicationBackoff is the delay before each retry of a replication call
+// that failed outright (dial/timeout error, not a log-consistency
+// rejection, which is handled separately by decrementing nextIndex).
+var replicationBackoff = []time.Duration{50 * time.Millisecond, 200 * time.Millisecond, 800 * time.Millisecond}
+
+func (n *Node) call(ctx context.Context, peer string, typ rpc.MsgType, req, resp interface{}) error {
+	n.mu.Lock()
+	client, ok := n.clients[peer]
+	n.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("raft: no rpc client for peer %s", peer)
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	payload, err := client.Call(ctx, typ, body, rpcCallTimeout)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, resp)
+}
+
+// callWithRetry is call plus a bounded exponential-backoff retry loop, for
+// fan-out paths (heartbeats, replication) where a single dropped packet
+// shouldn't make the leader give up on a peer.
+func (n *Node) callWithRetry(ctx context.Context, peer string, typ rpc.MsgType, req, resp interface{}) error {
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		lastErr = n.call(ctx, peer, typ, req, resp)
+		if lastErr == nil || attempt >= len(replicationBackoff) {
+			return lastErr
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(replicationBackoff[attempt]):
+		}
+	}
+}
@@ -0,0 +1,738 @@
+// Package server holds the Node type that backs a GoMiniDistro process: its
+// client-facing HTTP handlers (/put, /get, /delete, /watch, /cluster/*) and
+// the glue between them and the Raft, storage and cluster packages. It's
+// split out from package main so non-main code - currently the simulations
+// package - can construct and drive Nodes directly instead of shelling out
+// to a real binary.
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"GoMiniDistro/src/cluster"
+	"GoMiniDistro/src/internal/ws"
+	"GoMiniDistro/src/raft"
+	"GoMiniDistro/src/storage"
+)
+
+// eventRingSize bounds how many past watch events a node keeps around for
+// ?fromRevision= replay; older events are simply unavailable to replay.
+const eventRingSize = 256
+
+// watchSubscriberBuffer is how many unconsumed events a /watch client can
+// fall behind by before it's considered lagged and disconnected.
+const watchSubscriberBuffer = 32
+
+// watchEvent is one change-feed notification, fanned out to subscribers
+// whose key or prefix matches after a Put/Delete commits.
+type watchEvent struct {
+	Op       string `json:"op"`
+	Key      string `json:"key"`
+	Value    string `json:"value,omitempty"`
+	Revision uint64 `json:"revision"`
+}
+
+type watchSubscriber struct {
+	key    string
+	prefix string
+	ch     chan watchEvent
+}
+
+func (s *watchSubscriber) matches(ev watchEvent) bool {
+	if s.key != "" {
+		return ev.Key == s.key
+	}
+	return strings.HasPrefix(ev.Key, s.prefix)
+}
+
+// proposeTimeout bounds how long a client write waits for the Raft log
+// entry it produced to be committed.
+const proposeTimeout = 2 * time.Second
+
+// Role says whether this node's shard is the one the cluster's consistent
+// hash ring points clients at (RoleShard) or a plain member of that
+// shard's Raft replica set that never appears in the ring (RoleReplica).
+type Role string
+
+const (
+	RoleShard   Role = "shard"
+	RoleReplica Role = "replica"
+)
+
+type NodeConfig struct {
+	Peers       []string
+	SelfAddress string
+	WAL         *storage.WAL
+	DataDir     string
+	InitialData map[string]string
+	WALSeq      uint64
+	Role        Role
+	ShardID     string
+	Cluster     *cluster.Cluster
+
+	// Dialer, if set, is passed through to the Raft layer in place of plain
+	// TCP dialing. Only the simulation harness sets this.
+	Dialer raft.Dialer
+}
+
+type Node struct {
+	data        map[string]string
+	mu          sync.RWMutex
+	peers       []string
+	selfAddress string
+	raftNode    *raft.Node
+	wal         *storage.WAL
+	dataDir     string
+	walSeq      uint64
+	role        Role
+	shardID     string
+	cluster     *cluster.Cluster
+	httpClient  *http.Client
+
+	revision    uint64
+	events      []watchEvent
+	subscribers map[uint64]*watchSubscriber
+	nextSubID   uint64
+}
+
+func NewNode(cfg NodeConfig) *Node {
+	data := cfg.InitialData
+	if data == nil {
+		data = make(map[string]string)
+	}
+	n := &Node{
+		data:        data,
+		peers:       cfg.Peers,
+		selfAddress: cfg.SelfAddress,
+		wal:         cfg.WAL,
+		dataDir:     cfg.DataDir,
+		walSeq:      cfg.WALSeq,
+		role:        cfg.Role,
+		shardID:     cfg.ShardID,
+		cluster:     cfg.Cluster,
+		httpClient:  &http.Client{Timeout: 2 * time.Second},
+		subscribers: make(map[uint64]*watchSubscriber),
+	}
+	n.raftNode = raft.NewNode(raft.Config{
+		SelfAddress: cfg.SelfAddress,
+		Peers:       cfg.Peers,
+		Apply:       n.apply,
+		Dialer:      cfg.Dialer,
+	})
+	return n
+}
+
+// RaftNode exposes the underlying Raft participant for callers that need to
+// drive it directly - starting it, listening for its RPCs, or (the
+// simulation harness) wiring up peers after construction.
+func (n *Node) RaftNode() *raft.Node {
+	return n.raftNode
+}
+
+// SelfAddress returns the client-facing address this node was configured
+// with.
+func (n *Node) SelfAddress() string {
+	return n.selfAddress
+}
+
+// Data returns a snapshot of this node's key/value data.
+func (n *Node) Data() map[string]string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return copyMap(n.data)
+}
+
+// RegisterRoutes wires n's client-facing HTTP handlers onto mux. Both
+// main.go and the simulations package use this so the route list only
+// needs to be kept in one place.
+func RegisterRoutes(mux *http.ServeMux, n *Node) {
+	mux.HandleFunc("/put", n.Put)
+	mux.HandleFunc("/get", n.Get)
+	mux.HandleFunc("/delete", n.Delete)
+	mux.HandleFunc("/display", n.DisplayData)
+	mux.HandleFunc("/watch", n.Watch)
+	mux.HandleFunc("/cluster/join", n.ClusterJoin)
+	mux.HandleFunc("/cluster/leave", n.ClusterLeave)
+	mux.HandleFunc("/cluster/members", n.ClusterMembers)
+}
+
+// apply is invoked by the Raft layer once a log entry has been committed on
+// a majority of nodes. It is the only place n.data is mutated, and every
+// mutation is durably logged to the WAL before n.data is updated. The
+// append and the n.data/n.walSeq update happen under the same n.mu
+// critical section - not just the update - so SnapshotLoop can never
+// observe a record that's already durable in the WAL but not yet reflected
+// in n.data: that window is exactly what let WAL.Truncate discard a
+// committed record that no snapshot had captured.
+func (n *Node) apply(cmd raft.Cmd) {
+	rec := storage.Record{Op: cmd.Op, Key: cmd.Key, Value: cmd.Value}
+
+	n.mu.Lock()
+	seq, err := n.wal.Append(rec)
+	if err != nil {
+		n.mu.Unlock()
+		log.Printf("wal: failed to append %s %s: %v", cmd.Op, cmd.Key, err)
+		return
+	}
+	n.walSeq = seq
+	switch cmd.Op {
+	case "put":
+		n.data[cmd.Key] = cmd.Value
+	case "delete":
+		delete(n.data, cmd.Key)
+	}
+	n.revision++
+	ev := watchEvent{Op: cmd.Op, Key: cmd.Key, Value: cmd.Value, Revision: n.revision}
+	n.events = append(n.events, ev)
+	if len(n.events) > eventRingSize {
+		n.events = n.events[len(n.events)-eventRingSize:]
+	}
+	n.mu.Unlock()
+
+	n.publish(ev)
+}
+
+// publish fans ev out to every subscriber whose key or prefix matches it.
+// A subscriber whose channel is already full is lagging too far behind to
+// catch up safely, so it's dropped rather than risk blocking the apply
+// path (and therefore every client write) on a slow watcher.
+func (n *Node) publish(ev watchEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for id, sub := range n.subscribers {
+		if !sub.matches(ev) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			close(sub.ch)
+			delete(n.subscribers, id)
+		}
+	}
+}
+
+// eventsSince returns every retained event with a revision greater than
+// from, for a /watch client replaying from a known point.
+func (n *Node) eventsSince(from uint64) []watchEvent {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	var out []watchEvent
+	for _, ev := range n.events {
+		if ev.Revision > from {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// addSubscriber registers sub and returns its ID for later removal.
+func (n *Node) addSubscriber(sub *watchSubscriber) uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nextSubID++
+	id := n.nextSubID
+	n.subscribers[id] = sub
+	return id
+}
+
+func (n *Node) removeSubscriber(id uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.subscribers, id)
+}
+
+// SnapshotLoop periodically writes the full data set to disk and truncates
+// the WAL entries it now makes redundant. Exported so main.go, outside this
+// package, can run it; the simulation harness does not start it.
+func (n *Node) SnapshotLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		// n.mu is held from the copy through Truncate so apply can't append
+		// a record after the copy that Truncate would then discard.
+		n.mu.Lock()
+		state := &storage.State{Data: copyMap(n.data), Seq: n.walSeq}
+
+		if state.Seq == 0 {
+			n.mu.Unlock()
+			continue // nothing new to snapshot
+		}
+		if err := storage.WriteSnapshot(n.dataDir, state); err != nil {
+			n.mu.Unlock()
+			log.Printf("snapshot: write failed: %v", err)
+			continue
+		}
+		if err := n.wal.Truncate(); err != nil {
+			log.Printf("snapshot: wal truncate failed: %v", err)
+		}
+		n.mu.Unlock()
+	}
+}
+
+// flushIntervalBatch is how often the WAL is fsynced under storage.SyncBatch.
+// That policy defers fsync to an explicit Flush call; without something
+// calling it periodically, "batched" durability would be indistinguishable
+// from no durability at all.
+const flushIntervalBatch = 200 * time.Millisecond
+
+// FlushLoop periodically fsyncs the WAL while it's running under
+// storage.SyncBatch. It returns immediately under storage.SyncAlways, which
+// already fsyncs on every Append and needs nothing further.
+func (n *Node) FlushLoop() {
+	if n.wal.Policy() != storage.SyncBatch {
+		return
+	}
+	ticker := time.NewTicker(flushIntervalBatch)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := n.wal.Flush(); err != nil {
+			log.Printf("wal: batch flush failed: %v", err)
+		}
+	}
+}
+
+func copyMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// forwardToOwningShard sends the client a 307 pointing at the shard that
+// owns key on the consistent hash ring, if that isn't this node's shard.
+func (n *Node) forwardToOwningShard(w http.ResponseWriter, r *http.Request, key string) bool {
+	shardID, address, ok := n.cluster.Owner(key)
+	if !ok || shardID == n.shardID {
+		return false
+	}
+	http.Redirect(w, r, "http://"+address+r.URL.Path+"?"+r.URL.RawQuery, http.StatusTemporaryRedirect)
+	return true
+}
+
+// redirectToLeader sends the client a 307 pointing at the node this
+// follower believes is the current Raft leader.
+func (n *Node) redirectToLeader(w http.ResponseWriter, r *http.Request) bool {
+	if n.raftNode.IsLeader() {
+		return false
+	}
+	leader := n.raftNode.Leader()
+	if leader == "" || leader == n.selfAddress {
+		http.Error(w, "no leader available, try again", http.StatusServiceUnavailable)
+		return true
+	}
+	http.Redirect(w, r, "http://"+leader+r.URL.Path+"?"+r.URL.RawQuery, http.StatusTemporaryRedirect)
+	return true
+}
+
+func (n *Node) Put(w http.ResponseWriter, r *http.Request) {
+
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	defer r.Body.Close()
+
+	key, keyOk := body["key"]
+	value, valueOk := body["value"]
+	if !keyOk || !valueOk {
+		http.Error(w, "Missing key or value in request", http.StatusBadRequest)
+		return
+	}
+
+	if n.forwardToOwningShard(w, r, key) {
+		return
+	}
+	if n.redirectToLeader(w, r) {
+		return
+	}
+
+	ok, isLeader := n.raftNode.Propose(r.Context(), raft.Cmd{Op: "put", Key: key, Value: value}, proposeTimeout)
+	if !isLeader {
+		http.Error(w, "Lost leadership, retry", http.StatusServiceUnavailable)
+		return
+	}
+	if !ok {
+		http.Error(w, "Timed out waiting for replication", http.StatusGatewayTimeout)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Stored: %s -> %s\n", key, value)
+}
+
+func (n *Node) Get(w http.ResponseWriter, r *http.Request) {
+	// Extract key from the query parameters
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "Missing key in request", http.StatusBadRequest)
+		return
+	}
+
+	if n.forwardToOwningShard(w, r, key) {
+		return
+	}
+
+	// Retrieve the value from the database
+	n.mu.RLock()
+	value, exists := n.data[key]
+	n.mu.RUnlock()
+
+	// Respond to the client
+	if !exists {
+		http.Error(w, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Value: %s\n", value)
+}
+
+func (n *Node) Delete(w http.ResponseWriter, r *http.Request) {
+	// Ensure the request uses the DELETE method
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "Missing key in request", http.StatusBadRequest)
+		return
+	}
+
+	if n.forwardToOwningShard(w, r, key) {
+		return
+	}
+	if n.redirectToLeader(w, r) {
+		return
+	}
+
+	ok, isLeader := n.raftNode.Propose(r.Context(), raft.Cmd{Op: "delete", Key: key}, proposeTimeout)
+	if !isLeader {
+		http.Error(w, "Lost leadership, retry", http.StatusServiceUnavailable)
+		return
+	}
+	if !ok {
+		http.Error(w, "Timed out waiting for replication", http.StatusGatewayTimeout)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Deleted key: %s\n", key)
+}
+
+func (n *Node) DisplayData(w http.ResponseWriter, r *http.Request) {
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	jsonData, err := json.Marshal(n.data)
+	if err != nil {
+		http.Error(w, "Error encoding data to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonData)
+
+}
+
+// Watch handles GET /watch, upgrading to a WebSocket and streaming
+// {op,key,value,revision} events for a single key (?key=) or everything
+// under a prefix (?prefix=). ?fromRevision=X replays retained events newer
+// than X before switching to live updates.
+func (n *Node) Watch(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	prefix := r.URL.Query().Get("prefix")
+	if key == "" && prefix == "" {
+		http.Error(w, "must specify 'key' or 'prefix'", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := ws.Upgrade(w, r)
+	if err != nil {
+		http.Error(w, "websocket upgrade failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer conn.Close()
+
+	sub := &watchSubscriber{key: key, prefix: prefix, ch: make(chan watchEvent, watchSubscriberBuffer)}
+
+	var replay []watchEvent
+	if fr := r.URL.Query().Get("fromRevision"); fr != "" {
+		if from, err := strconv.ParseUint(fr, 10, 64); err == nil {
+			replay = n.eventsSince(from)
+		}
+	}
+
+	id := n.addSubscriber(sub)
+	defer n.removeSubscriber(id)
+
+	// The client never sends anything but pings and an eventual close frame,
+	// but something still has to read the connection to see them - and to
+	// notice the client is simply gone, since r.Context() isn't reliably
+	// cancelled once the connection has been hijacked. ReadMessage answers
+	// pings on its own; readDone closes once it returns an error (a close
+	// frame or the client disappearing), which is this goroutine's only job.
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		// This goroutine runs detached from the HTTP handler's call stack,
+		// so net/http's per-connection recover can't catch a panic here -
+		// one would take the whole process down instead of just this
+		// connection. readFrame rejects oversized frames itself; this
+		// recover is defense in depth against whatever's next.
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("watch: read loop panic: %v", r)
+			}
+		}()
+		for {
+			if _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, ev := range replay {
+		if !sub.matches(ev) {
+			continue
+		}
+		payload, _ := json.Marshal(ev)
+		if err := conn.WriteText(payload); err != nil {
+			return
+		}
+	}
+
+	for {
+		select {
+		case ev, ok := <-sub.ch:
+			if !ok {
+				payload, _ := json.Marshal(map[string]string{"err": "lagged"})
+				conn.WriteText(payload)
+				return
+			}
+			payload, _ := json.Marshal(ev)
+			if err := conn.WriteText(payload); err != nil {
+				return
+			}
+		case <-readDone:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// ClusterJoin handles POST /cluster/join, adding a shard to this node's
+// view of the ring. It does not itself propagate the join further; a newly
+// joining shard announces itself to every seed it was given.
+func (n *Node) ClusterJoin(w http.ResponseWriter, r *http.Request) {
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	shardID, address := body["shardId"], body["address"]
+	if shardID == "" || address == "" {
+		http.Error(w, "Missing 'shardId' or 'address' in request", http.StatusBadRequest)
+		return
+	}
+
+	n.cluster.Join(shardID, address)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Shard joined: %s -> %s\n", shardID, address)
+}
+
+// ClusterLeave handles POST /cluster/leave.
+func (n *Node) ClusterLeave(w http.ResponseWriter, r *http.Request) {
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	shardID := body["shardId"]
+	if shardID == "" {
+		http.Error(w, "Missing 'shardId' in request", http.StatusBadRequest)
+		return
+	}
+
+	n.cluster.Leave(shardID)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Shard left: %s\n", shardID)
+}
+
+// ClusterMembers handles GET /cluster/members.
+func (n *Node) ClusterMembers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(n.cluster.Members()); err != nil {
+		http.Error(w, "Error encoding members to JSON", http.StatusInternalServerError)
+	}
+}
+
+// fanoutBackoff bounds retries of background node-to-node HTTP calls
+// (cluster join announcements, rebalance handoffs) that have no client
+// waiting on them and so can afford to retry transient failures.
+var fanoutBackoff = []time.Duration{50 * time.Millisecond, 200 * time.Millisecond, 800 * time.Millisecond}
+
+// doWithRetry runs req (freshly built per attempt, since a request body
+// can't be replayed once sent) up to len(fanoutBackoff)+1 times, backing
+// off between attempts and giving up early if ctx is cancelled.
+func (n *Node) doWithRetry(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := n.httpClient.Do(req)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if attempt >= len(fanoutBackoff) {
+			return nil, lastErr
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(fanoutBackoff[attempt]):
+		}
+	}
+}
+
+// AnnounceToSeed tells an existing shard about this node's shard, and pulls
+// back its full membership view so this node converges on the same ring
+// without needing every shard to know about every join directly.
+func (n *Node) AnnounceToSeed(seed string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	joinBody, _ := json.Marshal(map[string]string{"shardId": n.shardID, "address": n.selfAddress})
+	resp, err := n.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+seed+"/cluster/join", bytes.NewReader(joinBody))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	membersResp, err := n.doWithRetry(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, "http://"+seed+"/cluster/members", nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer membersResp.Body.Close()
+
+	var members map[string]string
+	if err := json.NewDecoder(membersResp.Body).Decode(&members); err != nil {
+		return err
+	}
+	for shardID, address := range members {
+		n.cluster.Join(shardID, address)
+	}
+	return nil
+}
+
+// RebalanceLoop periodically hands off keys that the ring now routes to a
+// different shard, so growing the cluster actually redistributes load
+// instead of just changing where new keys land.
+func (n *Node) RebalanceLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		n.mu.RLock()
+		keys := make([]string, 0, len(n.data))
+		for k := range n.data {
+			keys = append(keys, k)
+		}
+		n.mu.RUnlock()
+
+		for _, key := range keys {
+			shardID, address, ok := n.cluster.Owner(key)
+			if !ok || shardID == n.shardID || !n.raftNode.IsLeader() {
+				continue
+			}
+
+			n.mu.RLock()
+			value, exists := n.data[key]
+			n.mu.RUnlock()
+			if !exists {
+				continue
+			}
+
+			body, _ := json.Marshal(map[string]string{"key": key, "value": value})
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			resp, err := n.doWithRetry(ctx, func() (*http.Request, error) {
+				req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+address+"/put", bytes.NewReader(body))
+				if err != nil {
+					return nil, err
+				}
+				req.Header.Set("Content-Type", "application/json")
+				return req, nil
+			})
+			if err != nil {
+				log.Printf("rebalance: failed handing %q to shard %s: %v", key, shardID, err)
+				cancel()
+				continue
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				cancel()
+				continue
+			}
+
+			n.raftNode.Propose(ctx, raft.Cmd{Op: "delete", Key: key}, proposeTimeout)
+			cancel()
+		}
+	}
+}
+
+// LeaderAnnounceLoop keeps this shard's entry on the cluster ring pointed at
+// whichever node is currently Raft leader. Cluster.Join overwrites the
+// shard's address every time it's called, so the leader re-announcing on a
+// timer is enough to make the ring self-heal after an election: the old
+// leader simply stops renewing, and the new one starts. Only Role == RoleShard
+// nodes call this; a replica that isn't on the ring has nothing to announce.
+//
+// This only keeps the node's own view of the ring current. A multi-shard
+// deployment still learns about another shard's leader change the same way
+// it learns about that shard at all today - via AnnounceToSeed/ClusterJoin
+// at startup - so a remote shard's ring entry for us can still go stale
+// between those exchanges. Re-announcing to known peer shards on leader
+// change would close that gap, but this node doesn't currently keep a list
+// of peer shard seeds around to do that with.
+func (n *Node) LeaderAnnounceLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if n.raftNode.IsLeader() {
+			n.cluster.Join(n.shardID, n.selfAddress)
+		}
+	}
+}
@@ -0,0 +1,455 @@
+// Package simulations lets tests (and an interactive /sim HTTP API) stand up
+// a small GoMiniDistro cluster inside a single process and then twist the
+// network between its nodes - latency, packet loss, full partitions - to
+// see how Raft replication behaves. It's inspired by go-ethereum's
+// p2p/simulations, which does the same thing for devp2p.
+//
+// The original request for this package described wiring nodes together
+// with an in-memory http.RoundTripper and no real sockets. That fit the
+// repo's original parent/child-over-HTTP design, but chunk0-3 moved
+// inter-node replication onto a framed TCP protocol (internal/rpc) with no
+// HTTP seam to intercept. So this harness runs every node on a real
+// loopback listener - both its client-facing HTTP API and its Raft RPC
+// server - and injects faults at the TCP dial layer instead, via the
+// raft.Dialer hook each Node accepts.
+package simulations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"GoMiniDistro/src/cluster"
+	"GoMiniDistro/src/internal/rpc"
+	"GoMiniDistro/src/server"
+	"GoMiniDistro/src/storage"
+)
+
+// simShardID is the shard every harness node belongs to. The harness always
+// models a single replica set rather than a multi-shard deployment, so
+// there's nothing for forwardToOwningShard to redirect between - every node
+// shares this ID and the ring exists only because NodeConfig requires one.
+const simShardID = "sim"
+
+// simLeaderAnnounceInterval drives each simulated node's LeaderAnnounceLoop.
+// Faults in a test can flip leadership quickly, so this is shorter than
+// main.go's real-deployment default to keep the ring converged on whichever
+// node the test expects to be current leader.
+const simLeaderAnnounceInterval = 100 * time.Millisecond
+
+// pairFault is the network condition currently injected on dials from one
+// node to another.
+type pairFault struct {
+	partitioned bool
+	latency     time.Duration
+	dropPercent int
+}
+
+type nodeHandle struct {
+	node     *server.Node
+	listener net.Listener
+	rpcAddr  string
+	dataDir  string
+}
+
+// Harness owns a set of in-process Nodes and the simulated network between
+// them. The zero value is not usable; call New.
+type Harness struct {
+	mu          sync.Mutex
+	nodes       map[string]*nodeHandle
+	rpcAddrToID map[string]string
+	faults      map[[2]string]*pairFault
+	ring        *cluster.Cluster
+}
+
+// New returns an empty Harness; populate it with CreateNode.
+func New() *Harness {
+	return &Harness{
+		nodes:       make(map[string]*nodeHandle),
+		rpcAddrToID: make(map[string]string),
+		faults:      make(map[[2]string]*pairFault),
+		ring:        cluster.NewCluster(),
+	}
+}
+
+// CreateNode starts a new Node named id, initially replicating with peers
+// (given as other nodes' IDs, which must already exist), and returns its
+// client-facing address.
+func (h *Harness) CreateNode(id string, peers ...string) (string, error) {
+	h.mu.Lock()
+	if _, exists := h.nodes[id]; exists {
+		h.mu.Unlock()
+		return "", fmt.Errorf("simulations: node %q already exists", id)
+	}
+	var peerAddrs []string
+	for _, p := range peers {
+		nh, ok := h.nodes[p]
+		if !ok {
+			h.mu.Unlock()
+			return "", fmt.Errorf("simulations: unknown peer %q", p)
+		}
+		peerAddrs = append(peerAddrs, nh.node.SelfAddress())
+	}
+	h.mu.Unlock()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	addr := ln.Addr().String()
+
+	dataDir, err := os.MkdirTemp("", "gominidistro-sim-"+id+"-")
+	if err != nil {
+		ln.Close()
+		return "", err
+	}
+
+	wal, err := storage.OpenWAL(dataDir, storage.SyncBatch)
+	if err != nil {
+		ln.Close()
+		return "", err
+	}
+
+	n := server.NewNode(server.NodeConfig{
+		Peers:       peerAddrs,
+		SelfAddress: addr,
+		WAL:         wal,
+		DataDir:     dataDir,
+		Role:        server.RoleShard,
+		ShardID:     simShardID,
+		Cluster:     h.ring,
+		Dialer:      h.dialerFor(id),
+	})
+
+	raftAddr, err := n.RaftNode().ListenAddress()
+	if err != nil {
+		ln.Close()
+		return "", err
+	}
+	rpcServer := rpc.NewServer(n.RaftNode().HandleRPC)
+	if err := rpcServer.Listen(raftAddr); err != nil {
+		ln.Close()
+		return "", err
+	}
+
+	mux := http.NewServeMux()
+	server.RegisterRoutes(mux, n)
+	go http.Serve(ln, mux)
+
+	n.RaftNode().Start()
+	go n.FlushLoop()
+	go n.LeaderAnnounceLoop(simLeaderAnnounceInterval)
+	h.ring.Join(simShardID, addr)
+
+	// Every existing peer needs this new node added to its own replica set
+	// too - AddPeer is symmetric by convention, matching Config.Peers.
+	h.mu.Lock()
+	for _, p := range peers {
+		h.nodes[p].node.RaftNode().AddPeer(addr)
+	}
+	h.nodes[id] = &nodeHandle{node: n, listener: ln, rpcAddr: raftAddr, dataDir: dataDir}
+	h.rpcAddrToID[raftAddr] = id
+	h.mu.Unlock()
+
+	return addr, nil
+}
+
+// ConnectAsChild wires childID and parentID into each other's Raft replica
+// set. The name mirrors the legacy parent/child terminology this request
+// was written against; the current design has no asymmetric parent/child
+// relationship, just peers that replicate to each other, so both directions
+// are added.
+func (h *Harness) ConnectAsChild(childID, parentID string) error {
+	h.mu.Lock()
+	child, ok1 := h.nodes[childID]
+	parent, ok2 := h.nodes[parentID]
+	h.mu.Unlock()
+	if !ok1 {
+		return fmt.Errorf("simulations: unknown node %q", childID)
+	}
+	if !ok2 {
+		return fmt.Errorf("simulations: unknown node %q", parentID)
+	}
+
+	child.node.RaftNode().AddPeer(parent.node.SelfAddress())
+	parent.node.RaftNode().AddPeer(child.node.SelfAddress())
+	return nil
+}
+
+// PartitionNodes makes every new dial between a and b fail, in both
+// directions, until HealPartition is called. A connection either node
+// already holds open to the other is not closed, so RPCs over it still
+// go through.
+func (h *Harness) PartitionNodes(a, b string) {
+	h.mutateFault(a, b, func(f *pairFault) { f.partitioned = true })
+	h.mutateFault(b, a, func(f *pairFault) { f.partitioned = true })
+}
+
+// HealPartition reverses a prior PartitionNodes between a and b.
+func (h *Harness) HealPartition(a, b string) {
+	h.mutateFault(a, b, func(f *pairFault) { f.partitioned = false })
+	h.mutateFault(b, a, func(f *pairFault) { f.partitioned = false })
+}
+
+// SetLatency delays every dial a makes to b by d before it proceeds.
+func (h *Harness) SetLatency(a, b string, d time.Duration) {
+	h.mutateFault(a, b, func(f *pairFault) { f.latency = d })
+}
+
+// DropPercent fails pct percent of a's dials to b, simulating packet loss.
+func (h *Harness) DropPercent(a, b string, pct int) {
+	h.mutateFault(a, b, func(f *pairFault) { f.dropPercent = pct })
+}
+
+func (h *Harness) mutateFault(from, to string, mutate func(*pairFault)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := [2]string{from, to}
+	f, ok := h.faults[key]
+	if !ok {
+		f = &pairFault{}
+		h.faults[key] = f
+	}
+	mutate(f)
+}
+
+// dialerFor returns the raft.Dialer node selfID should use for every peer
+// connection it opens, applying whatever fault the harness currently has
+// configured for that directed pair before falling back to a real dial.
+func (h *Harness) dialerFor(selfID string) rpc.Dialer {
+	return func(addr string) (net.Conn, error) {
+		h.mu.Lock()
+		peerID := h.rpcAddrToID[addr]
+		var fault pairFault
+		if f, ok := h.faults[[2]string{selfID, peerID}]; ok {
+			fault = *f
+		}
+		h.mu.Unlock()
+
+		if fault.partitioned {
+			return nil, fmt.Errorf("simulations: %s is partitioned from %s", selfID, peerID)
+		}
+		if fault.dropPercent > 0 && rand.Intn(100) < fault.dropPercent {
+			return nil, fmt.Errorf("simulations: simulated packet drop from %s to %s", selfID, peerID)
+		}
+		if fault.latency > 0 {
+			time.Sleep(fault.latency)
+		}
+		return net.DialTimeout("tcp", addr, 2*time.Second)
+	}
+}
+
+// Snapshot returns a deep copy of every node's key/value data, keyed by
+// node ID then key.
+func (h *Harness) Snapshot() map[string]map[string]string {
+	h.mu.Lock()
+	handles := make(map[string]*server.Node, len(h.nodes))
+	for id, nh := range h.nodes {
+		handles[id] = nh.node
+	}
+	h.mu.Unlock()
+
+	out := make(map[string]map[string]string, len(handles))
+	for id, n := range handles {
+		out[id] = n.Data()
+	}
+	return out
+}
+
+// IsLeader reports whether id currently believes it's the Raft leader of
+// its replica set.
+func (h *Harness) IsLeader(id string) (bool, error) {
+	h.mu.Lock()
+	nh, ok := h.nodes[id]
+	h.mu.Unlock()
+	if !ok {
+		return false, fmt.Errorf("simulations: unknown node %q", id)
+	}
+	return nh.node.RaftNode().IsLeader(), nil
+}
+
+// Node returns the underlying server.Node for id, for tests that need
+// lower-level access (e.g. calling Put directly).
+func (h *Harness) Node(id string) (*server.Node, error) {
+	h.mu.Lock()
+	nh, ok := h.nodes[id]
+	h.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("simulations: unknown node %q", id)
+	}
+	return nh.node, nil
+}
+
+// Close closes every node's client-facing HTTP listener and removes its
+// temporary data directory. The nodes' Raft RPC servers and background
+// loops are not stopped.
+func (h *Harness) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, nh := range h.nodes {
+		nh.listener.Close()
+		os.RemoveAll(nh.dataDir)
+	}
+}
+
+// Put sends a PUT through id's client-facing HTTP API, following the 307
+// redirects it issues for a non-owning shard or non-leader node.
+func (h *Harness) Put(id, key, value string) error {
+	addr, err := h.addrOf(id)
+	if err != nil {
+		return err
+	}
+	body, _ := json.Marshal(map[string]string{"key": key, "value": value})
+	resp, err := http.Post("http://"+addr+"/put", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("simulations: put %q on %s: %s", key, id, resp.Status)
+	}
+	return nil
+}
+
+// Get reads key through id's client-facing HTTP API.
+func (h *Harness) Get(id, key string) (string, bool, error) {
+	addr, err := h.addrOf(id)
+	if err != nil {
+		return "", false, err
+	}
+	resp, err := http.Get("http://" + addr + "/get?key=" + key)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("simulations: get %q on %s: %s", key, id, resp.Status)
+	}
+	var value string
+	if _, err := fmt.Fscanf(resp.Body, "Value: %s", &value); err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (h *Harness) addrOf(id string) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	nh, ok := h.nodes[id]
+	if !ok {
+		return "", fmt.Errorf("simulations: unknown node %q", id)
+	}
+	return nh.node.SelfAddress(), nil
+}
+
+// RegisterHTTP mounts a small JSON control API for interactive
+// experimentation with the harness:
+//
+//	POST /sim/nodes      {"id": "...", "peers": ["..."]}
+//	POST /sim/connect    {"child": "...", "parent": "..."}
+//	POST /sim/partition  {"a": "...", "b": "..."}
+//	POST /sim/heal       {"a": "...", "b": "..."}
+//	POST /sim/latency    {"a": "...", "b": "...", "ms": N}
+//	POST /sim/drop       {"a": "...", "b": "...", "percent": N}
+//	GET  /sim/snapshot
+func (h *Harness) RegisterHTTP(mux *http.ServeMux) {
+	mux.HandleFunc("/sim/nodes", h.handleCreateNode)
+	mux.HandleFunc("/sim/connect", h.handleConnect)
+	mux.HandleFunc("/sim/partition", h.handlePartition)
+	mux.HandleFunc("/sim/heal", h.handleHeal)
+	mux.HandleFunc("/sim/latency", h.handleLatency)
+	mux.HandleFunc("/sim/drop", h.handleDrop)
+	mux.HandleFunc("/sim/snapshot", h.handleSnapshot)
+}
+
+func (h *Harness) handleCreateNode(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ID    string   `json:"id"`
+		Peers []string `json:"peers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	addr, err := h.CreateNode(body.ID, body.Peers...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fmt.Fprintf(w, "Node created: %s -> %s\n", body.ID, addr)
+}
+
+func (h *Harness) handleConnect(w http.ResponseWriter, r *http.Request) {
+	var body struct{ Child, Parent string }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := h.ConnectAsChild(body.Child, body.Parent); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fmt.Fprintf(w, "Connected: %s -> %s\n", body.Child, body.Parent)
+}
+
+func (h *Harness) handlePartition(w http.ResponseWriter, r *http.Request) {
+	var body struct{ A, B string }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	h.PartitionNodes(body.A, body.B)
+	fmt.Fprintf(w, "Partitioned: %s <-> %s\n", body.A, body.B)
+}
+
+func (h *Harness) handleHeal(w http.ResponseWriter, r *http.Request) {
+	var body struct{ A, B string }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	h.HealPartition(body.A, body.B)
+	fmt.Fprintf(w, "Healed: %s <-> %s\n", body.A, body.B)
+}
+
+func (h *Harness) handleLatency(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		A, B string
+		Ms   int
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	h.SetLatency(body.A, body.B, time.Duration(body.Ms)*time.Millisecond)
+	fmt.Fprintf(w, "Latency set: %s -> %s: %dms\n", body.A, body.B, body.Ms)
+}
+
+func (h *Harness) handleDrop(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		A, B    string
+		Percent int
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	h.DropPercent(body.A, body.B, body.Percent)
+	fmt.Fprintf(w, "Drop rate set: %s -> %s: %d%%\n", body.A, body.B, body.Percent)
+}
+
+func (h *Harness) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.Snapshot()); err != nil {
+		http.Error(w, "Error encoding snapshot to JSON", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,149 @@
+package simulations
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newCluster creates n nodes, all replicating with each other, and waits for
+// a leader to be elected before returning its ID.
+func newCluster(t *testing.T, n int) (*Harness, []string, string) {
+	t.Helper()
+	h := New()
+	t.Cleanup(h.Close)
+
+	ids := make([]string, n)
+	for i := 0; i < n; i++ {
+		ids[i] = fmt.Sprintf("node%d", i)
+		peers := ids[:i]
+		if _, err := h.CreateNode(ids[i], peers...); err != nil {
+			t.Fatalf("CreateNode(%s): %v", ids[i], err)
+		}
+	}
+
+	leader, err := waitForLeader(h, ids, 3*time.Second)
+	if err != nil {
+		t.Fatalf("waitForLeader: %v", err)
+	}
+	return h, ids, leader
+}
+
+func waitForLeader(h *Harness, ids []string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		for _, id := range ids {
+			if ok, _ := h.IsLeader(id); ok {
+				return id, nil
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return "", fmt.Errorf("no leader elected among %v within %s", ids, timeout)
+}
+
+func waitForValue(h *Harness, id, key, want string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var last string
+	var lastOk bool
+	for time.Now().Before(deadline) {
+		value, ok, err := h.Get(id, key)
+		if err == nil && ok && value == want {
+			return nil
+		}
+		last, lastOk = value, ok
+		time.Sleep(20 * time.Millisecond)
+	}
+	return fmt.Errorf("node %s never saw %s=%s (last: value=%q ok=%v)", id, key, want, last, lastOk)
+}
+
+// TestWritesReachAllFollowers proves every follower eventually applies a
+// write sent straight to the leader.
+func TestWritesReachAllFollowers(t *testing.T) {
+	cases := []struct {
+		key, value string
+	}{
+		{"a", "1"},
+		{"b", "2"},
+		{"config/replicas", "3"},
+	}
+
+	h, ids, leader := newCluster(t, 3)
+
+	for _, tc := range cases {
+		if err := h.Put(leader, tc.key, tc.value); err != nil {
+			t.Fatalf("Put(%s, %s): %v", tc.key, tc.value, err)
+		}
+		for _, id := range ids {
+			if err := waitForValue(h, id, tc.key, tc.value, 2*time.Second); err != nil {
+				t.Errorf("case %s=%s: %v", tc.key, tc.value, err)
+			}
+		}
+	}
+}
+
+// TestPartitionedNodeCatchesUpOnReconnect proves a node that misses writes
+// while partitioned picks them up once the partition heals and the leader's
+// next heartbeat (or the write itself, retried) reaches it.
+func TestPartitionedNodeCatchesUpOnReconnect(t *testing.T) {
+	h, ids, leader := newCluster(t, 3)
+
+	var cutOff string
+	for _, id := range ids {
+		if id != leader {
+			cutOff = id
+			break
+		}
+	}
+
+	h.PartitionNodes(leader, cutOff)
+
+	if err := h.Put(leader, "k", "while-partitioned"); err != nil {
+		t.Fatalf("Put while partitioned: %v", err)
+	}
+	for _, id := range ids {
+		if id == cutOff {
+			continue
+		}
+		if err := waitForValue(h, id, "k", "while-partitioned", 2*time.Second); err != nil {
+			t.Fatalf("connected node %s: %v", id, err)
+		}
+	}
+
+	h.HealPartition(leader, cutOff)
+
+	if err := waitForValue(h, cutOff, "k", "while-partitioned", 3*time.Second); err != nil {
+		t.Fatalf("partitioned node did not catch up after healing: %v", err)
+	}
+}
+
+// TestConcurrentPutsSerialize proves concurrent Puts against the leader for
+// the same key all commit in some order, rather than being lost or
+// interleaved into a torn value, and every node converges on the winner.
+func TestConcurrentPutsSerialize(t *testing.T) {
+	h, ids, leader := newCluster(t, 3)
+
+	const writers = 10
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		go func(i int) {
+			errs <- h.Put(leader, "counter", fmt.Sprintf("v%d", i))
+		}(i)
+	}
+	for i := 0; i < writers; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("concurrent Put %d: %v", i, err)
+		}
+	}
+
+	final, ok, err := h.Get(leader, "counter")
+	if err != nil || !ok {
+		t.Fatalf("Get(counter) on leader: value=%q ok=%v err=%v", final, ok, err)
+	}
+
+	for _, id := range ids {
+		if err := waitForValue(h, id, "counter", final, 2*time.Second); err != nil {
+			t.Errorf("node %s did not converge on %q: %v", id, final, err)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Package storage gives a Node durability across restarts: a
+// write-ahead log (WAL) that every mutation is appended to before the
+// client is told it succeeded, and periodic snapshots of the full data set
+// so the WAL doesn't have to be replayed from the beginning of time.
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SyncPolicy controls how aggressively the WAL calls fsync.
+type SyncPolicy string
+
+const (
+	// SyncAlways fsyncs after every appended record.
+	SyncAlways SyncPolicy = "always"
+	// SyncBatch fsyncs once per Flush call, letting the caller batch
+	// several appends into one fsync.
+	SyncBatch SyncPolicy = "batch"
+)
+
+// Record is one WAL entry: a single Put or Delete, tagged with a
+// monotonically increasing sequence number so replay can skip anything a
+// snapshot already accounts for.
+type Record struct {
+	Op    string `json:"op"` // "put" or "delete"
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+	Seq   uint64 `json:"seq"`
+}
+
+// State is the full in-memory data set, snapshotted to disk periodically.
+type State struct {
+	Data map[string]string `json:"data"`
+	Seq  uint64            `json:"seq"`
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (s *State) MarshalBinary() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (s *State) UnmarshalBinary(b []byte) error {
+	return json.Unmarshal(b, s)
+}
+
+const walFileName = "wal.log"
+
+// WAL is an append-only log of length-prefixed Records.
+type WAL struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	policy SyncPolicy
+	seq    uint64
+}
+
+// OpenWAL opens (creating if necessary) the WAL file in dir.
+func OpenWAL(dir string, policy SyncPolicy) (*WAL, error) {
+	path := filepath.Join(dir, walFileName)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open wal: %w", err)
+	}
+	return &WAL{path: path, file: f, policy: policy}, nil
+}
+
+// Append writes rec to the log, assigning it the next sequence number, and
+// fsyncs before returning if the policy is SyncAlways.
+func (w *WAL) Append(rec Record) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.seq++
+	rec.Seq = w.seq
+
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return 0, err
+	}
+
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(payload)))
+
+	if _, err := w.file.Write(lenBuf[:]); err != nil {
+		return 0, err
+	}
+	if _, err := w.file.Write(payload); err != nil {
+		return 0, err
+	}
+
+	if w.policy == SyncAlways {
+		if err := w.file.Sync(); err != nil {
+			return 0, err
+		}
+	}
+
+	return rec.Seq, nil
+}
+
+// SeedSeq sets the WAL's starting sequence counter; used on startup so
+// newly appended records continue numbering from where the last snapshot
+// and replay left off instead of restarting at zero.
+func (w *WAL) SeedSeq(seq uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.seq = seq
+}
+
+// Flush fsyncs the WAL file; used under SyncBatch.
+func (w *WAL) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Sync()
+}
+
+// Policy reports the sync policy the WAL was opened with, so a caller can
+// decide whether it needs to drive Flush itself (SyncBatch never fsyncs on
+// its own).
+func (w *WAL) Policy() SyncPolicy {
+	return w.policy
+}
+
+// Close closes the underlying file.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// ReplayWAL reads every record in dir's WAL (if any) in order, invoking fn
+// for each one with Seq greater than afterSeq. It returns the highest Seq
+// observed, which may be greater than the WAL's own in-memory counter if
+// called before OpenWAL.
+func ReplayWAL(dir string, afterSeq uint64, fn func(Record)) (uint64, error) {
+	path := filepath.Join(dir, walFileName)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return afterSeq, nil
+	}
+	if err != nil {
+		return afterSeq, err
+	}
+	defer f.Close()
+
+	highest := afterSeq
+	for {
+		var lenBuf [4]byte
+		if _, err := io.ReadFull(f, lenBuf[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			// A truncated trailing record means a crash mid-write; stop
+			// replaying rather than failing startup.
+			break
+		}
+		size := binary.BigEndian.Uint32(lenBuf[:])
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(f, payload); err != nil {
+			break
+		}
+
+		var rec Record
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			break
+		}
+		if rec.Seq > highest {
+			highest = rec.Seq
+		}
+		if rec.Seq > afterSeq {
+			fn(rec)
+		}
+	}
+
+	return highest, nil
+}
+
+// Truncate discards every record already folded into a snapshot, replacing
+// the WAL file with an empty one so it doesn't grow without bound.
+func (w *WAL) Truncate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	return nil
+}
+
+const snapshotPrefix = "snapshot-"
+
+// WriteSnapshot atomically writes state to dir as a new snapshot file named
+// after its sequence number.
+func WriteSnapshot(dir string, state *State) error {
+	payload, err := state.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	final := filepath.Join(dir, fmt.Sprintf("%s%020d.bin", snapshotPrefix, state.Seq))
+	tmp := final + ".tmp"
+
+	if err := os.WriteFile(tmp, payload, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, final)
+}
+
+// LoadLatestSnapshot returns the most recent snapshot in dir, or an empty
+// State with Seq 0 if none exists yet.
+func LoadLatestSnapshot(dir string) (*State, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &State{Data: make(map[string]string)}, nil
+		}
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), snapshotPrefix) && strings.HasSuffix(e.Name(), ".bin") {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) == 0 {
+		return &State{Data: make(map[string]string)}, nil
+	}
+	sort.Strings(names) // zero-padded sequence numbers sort lexically
+
+	latest := names[len(names)-1]
+	payload, err := os.ReadFile(filepath.Join(dir, latest))
+	if err != nil {
+		return nil, err
+	}
+
+	state := &State{}
+	if err := state.UnmarshalBinary(payload); err != nil {
+		return nil, fmt.Errorf("corrupt snapshot %s: %w", latest, err)
+	}
+	if state.Data == nil {
+		state.Data = make(map[string]string)
+	}
+	return state, nil
+}
+
+// ParseSyncPolicy validates the --wal-sync flag value.
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch SyncPolicy(s) {
+	case SyncAlways, SyncBatch:
+		return SyncPolicy(s), nil
+	default:
+		return "", fmt.Errorf("invalid wal-sync policy %q, want %q or %q", s, SyncAlways, SyncBatch)
+	}
+}
+
+// SeqFromSnapshotName parses the sequence number out of a snapshot file
+// name without re-parsing the binary payload. Nothing in this tree calls it.
+func SeqFromSnapshotName(name string) (uint64, error) {
+	trimmed := strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), ".bin")
+	return strconv.ParseUint(trimmed, 10, 64)
+}